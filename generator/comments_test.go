@@ -0,0 +1,113 @@
+package generator
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	plugin "github.com/golang/protobuf/protoc-gen-go/plugin"
+)
+
+func commentsTestFile() *descriptor.FileDescriptorProto {
+	return &descriptor.FileDescriptorProto{
+		Name: proto.String("hello_world/service.proto"),
+		// Package left empty so the generated code has no enclosing Ruby
+		// module, keeping the expected indentation in this test predictable.
+		MessageType: []*descriptor.DescriptorProto{
+			{Name: proto.String("HelloRequest")},
+			{Name: proto.String("HelloReply")},
+		},
+		Service: []*descriptor.ServiceDescriptorProto{
+			{
+				Name: proto.String("Greeter"),
+				Method: []*descriptor.MethodDescriptorProto{
+					{
+						Name:       proto.String("Hello"),
+						InputType:  proto.String(".HelloRequest"),
+						OutputType: proto.String(".HelloReply"),
+					},
+				},
+			},
+		},
+		SourceCodeInfo: &descriptor.SourceCodeInfo{
+			Location: []*descriptor.SourceCodeInfo_Location{
+				{
+					// The Greeter service itself: a two-line leading comment
+					// with a blank line in the middle, and a leading space on
+					// each line the way protoc emits them.
+					Path:            []int32{6, 0},
+					LeadingComments: proto.String(" Greets people.\n\n More than once, if you insist.\n"),
+				},
+				{
+					// The Hello method: no leading comment, only a trailing
+					// one, which should still be picked up as a fallback.
+					Path:             []int32{6, 0, 2, 0},
+					TrailingComments: proto.String(" Says hello.\n"),
+				},
+			},
+		},
+	}
+}
+
+func TestYARDCommentsEmittedByDefault(t *testing.T) {
+	file := commentsTestFile()
+	req := &plugin.CodeGeneratorRequest{
+		FileToGenerate: []string{file.GetName()},
+		ProtoFile:      []*descriptor.FileDescriptorProto{file},
+	}
+
+	resp := New(req, "test").Generate()
+	if resp.GetError() != "" {
+		t.Fatalf("Generate() returned error: %s", resp.GetError())
+	}
+	content := resp.File[0].GetContent()
+
+	wantService := "# Greets people.\n" +
+		"#\n" +
+		"# More than once, if you insist.\n" +
+		"class GreeterService < Twirp::Service"
+	if !strings.Contains(content, wantService) {
+		t.Errorf("expected service doc comment (with blank line preserved) above the class, got:\n%s", content)
+	}
+
+	// The Client class reuses the same service-level comment.
+	wantClient := "# Greets people.\n" +
+		"#\n" +
+		"# More than once, if you insist.\n" +
+		"class GreeterClient < Twirp::Client"
+	if !strings.Contains(content, wantClient) {
+		t.Errorf("expected service doc comment above the client class, got:\n%s", content)
+	}
+
+	wantMethod := "  # Says hello.\n" +
+		"  #\n" +
+		"  # @param req [HelloRequest]\n" +
+		"  # @return [HelloReply]\n" +
+		"  rpc :Hello, HelloRequest, HelloReply, :ruby_method => :hello"
+	if !strings.Contains(content, wantMethod) {
+		t.Errorf("expected method doc comment (falling back to TrailingComments) above the rpc line, got:\n%s", content)
+	}
+}
+
+func TestEmitCommentsFalseOptsOut(t *testing.T) {
+	file := commentsTestFile()
+	req := &plugin.CodeGeneratorRequest{
+		FileToGenerate: []string{file.GetName()},
+		Parameter:      proto.String("emit_comments=false"),
+		ProtoFile:      []*descriptor.FileDescriptorProto{file},
+	}
+
+	resp := New(req, "test").Generate()
+	if resp.GetError() != "" {
+		t.Fatalf("Generate() returned error: %s", resp.GetError())
+	}
+	content := resp.File[0].GetContent()
+
+	if strings.Contains(content, "#") && strings.Contains(content, "Greets people") {
+		t.Errorf("expected no YARD comments with emit_comments=false, got:\n%s", content)
+	}
+	if !strings.Contains(content, "class GreeterService < Twirp::Service") {
+		t.Errorf("expected the service class to still be emitted, got:\n%s", content)
+	}
+}
@@ -0,0 +1,58 @@
+// Copyright 2018 Twitch Interactive, Inc.  All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the License is
+// located at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// or in the "license" file accompanying this file. This file is distributed on
+// an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package generator
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	plugin "github.com/golang/protobuf/protoc-gen-go/plugin"
+)
+
+// TestGenerateReportsUnresolvedMessageAsError verifies that an RPC
+// referencing a message type the generator can't find comes back as a
+// CodeGeneratorResponse.Error instead of a panic.
+func TestGenerateReportsUnresolvedMessageAsError(t *testing.T) {
+	file := &descriptor.FileDescriptorProto{
+		Name:    proto.String("hello_world/service.proto"),
+		Package: proto.String("hello_world"),
+		Service: []*descriptor.ServiceDescriptorProto{
+			{
+				Name: proto.String("Greeter"),
+				Method: []*descriptor.MethodDescriptorProto{
+					{
+						Name:       proto.String("Hello"),
+						InputType:  proto.String(".hello_world.DoesNotExist"),
+						OutputType: proto.String(".hello_world.DoesNotExist"),
+					},
+				},
+			},
+		},
+	}
+
+	req := &plugin.CodeGeneratorRequest{
+		FileToGenerate: []string{file.GetName()},
+		ProtoFile:      []*descriptor.FileDescriptorProto{file},
+	}
+
+	resp := New(req, "test").Generate()
+	if resp.GetError() == "" {
+		t.Fatal("expected Generate() to report an error instead of panicking")
+	}
+	if !strings.Contains(resp.GetError(), "DoesNotExist") {
+		t.Errorf("expected error to mention the unresolved type, got: %s", resp.GetError())
+	}
+}
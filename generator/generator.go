@@ -0,0 +1,537 @@
+// Copyright 2018 Twitch Interactive, Inc.  All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the License is
+// located at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// or in the "license" file accompanying this file. This file is distributed on
+// an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package generator turns a protoc CodeGeneratorRequest into Ruby Twirp
+// service/client code. It is importable on its own (not just from
+// protoc-gen-twirp_ruby's main package) so that downstream users can extend
+// it with a Plugin without forking the binary.
+package generator
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	plugin "github.com/golang/protobuf/protoc-gen-go/plugin"
+
+	"github.com/twitchtv/twirp-ruby/internal/gen/typemap"
+)
+
+// New builds a Generator for a single protoc invocation. version is
+// embedded in the "Code generated by ..." header of every emitted file.
+func New(genReq *plugin.CodeGeneratorRequest, version string) *Generator {
+	return &Generator{
+		version:             version,
+		genReq:              genReq,
+		fileToGoPackageName: make(map[*descriptor.FileDescriptorProto]string),
+		reg:                 typemap.New(genReq.ProtoFile),
+	}
+}
+
+// Generator holds the state for a single protoc invocation: the parsed
+// request, the resolved generator parameters, and the Plugins activated for
+// this run.
+type Generator struct {
+	version string
+	genReq  *plugin.CodeGeneratorRequest
+	params  generatorParams
+
+	reg                 *typemap.Registry
+	genFiles            []*descriptor.FileDescriptorProto
+	fileToGoPackageName map[*descriptor.FileDescriptorProto]string
+	activePlugins       []Plugin
+}
+
+// generatorParams holds the parsed protoc generator parameter string, i.e.
+// the comma-separated `key=value` pairs passed as
+// `--twirp_ruby_out=<params>:<outdir>`.
+type generatorParams struct {
+	// paths controls how the generated _twirp.rb file is located relative to
+	// the output directory: "source_relative" (the default) mirrors the
+	// input .proto's directory, "import" nests it under a directory derived
+	// from the Ruby package.
+	paths string
+
+	// requirePrefix, when set, causes the generated file to `require
+	// '<requirePrefix>/<pbFile>'` instead of `require_relative '<pbFile>'`,
+	// for apps that ship generated code inside a gem.
+	requirePrefix string
+
+	// emitComments controls whether proto comments are emitted as YARD
+	// docstrings above the generated classes and rpc lines. Defaults to true.
+	emitComments bool
+
+	// plugins lists the names of the registered Plugins to run, in order,
+	// from the `plugins=name1,name2` generator parameter.
+	plugins []string
+}
+
+// parseParameter parses the protoc generator parameter string into a
+// generatorParams, returning an error for unknown parameters or unsupported
+// values rather than silently ignoring them.
+//
+// The parameter string as a whole is comma-delimited, which collides with
+// "plugins=name1,name2" also wanting commas to separate its own list: a bare
+// token with no "=" (like the "name2" in that example) that follows a
+// "plugins" entry is taken as another plugin name. A bare token after any
+// other key is parsed as a key of its own with an empty value.
+func parseParameter(parameter string) (generatorParams, error) {
+	params := generatorParams{paths: "source_relative", emitComments: true}
+	if parameter == "" {
+		return params, nil
+	}
+
+	var lastKey string
+	for _, p := range strings.Split(parameter, ",") {
+		if p == "" {
+			continue
+		}
+
+		key, value := p, ""
+		hasValue := false
+		if i := strings.Index(p, "="); i >= 0 {
+			key, value = p[:i], p[i+1:]
+			hasValue = true
+		}
+
+		if !hasValue && lastKey == "plugins" {
+			params.plugins = append(params.plugins, key)
+			continue
+		}
+
+		switch key {
+		case "paths":
+			if value != "source_relative" && value != "import" {
+				return params, fmt.Errorf("unsupported paths value %q, expected \"source_relative\" or \"import\"", value)
+			}
+			params.paths = value
+		case "require_prefix":
+			params.requirePrefix = value
+		case "emit_comments":
+			switch value {
+			case "false":
+				params.emitComments = false
+			case "true":
+				params.emitComments = true
+			default:
+				return params, fmt.Errorf("unsupported emit_comments value %q, expected \"true\" or \"false\"", value)
+			}
+		case "plugins":
+			params.plugins = nil
+			if value != "" {
+				params.plugins = append(params.plugins, value)
+			}
+		default:
+			return params, fmt.Errorf("unknown generator parameter %q", key)
+		}
+
+		lastKey = key
+	}
+
+	return params, nil
+}
+
+func fileDescSliceContains(slice []*descriptor.FileDescriptorProto, f *descriptor.FileDescriptorProto) bool {
+	for _, sf := range slice {
+		if f == sf {
+			return true
+		}
+	}
+	return false
+}
+
+// genError marks a panic raised by generator internals (e.g. an unresolved
+// message type) as recoverable: Generate catches it and reports it to
+// protoc as a CodeGeneratorResponse.Error instead of crashing the process
+// with a stack trace.
+type genError string
+
+func (e genError) Error() string { return string(e) }
+
+func (g *Generator) Generate() (resp *plugin.CodeGeneratorResponse) {
+	resp = new(plugin.CodeGeneratorResponse)
+	resp.SupportedFeatures = proto.Uint64(uint64(plugin.CodeGeneratorResponse_FEATURE_PROTO3_OPTIONAL))
+
+	defer func() {
+		if r := recover(); r != nil {
+			ge, ok := r.(genError)
+			if !ok {
+				panic(r)
+			}
+			resp.Error = proto.String(ge.Error())
+		}
+	}()
+
+	params, err := parseParameter(g.genReq.GetParameter())
+	if err != nil {
+		resp.Error = proto.String(err.Error())
+		return resp
+	}
+	g.params = params
+
+	for _, name := range params.plugins {
+		p, ok := registeredPlugins[name]
+		if !ok {
+			resp.Error = proto.String(fmt.Sprintf("unknown plugin %q", name))
+			return resp
+		}
+		g.activePlugins = append(g.activePlugins, p)
+	}
+
+	g.findProtoFilesToGenerate()
+
+	for _, f := range g.genFiles {
+		twirpFileName := g.TwirpFileName(f)
+		pbFileRelativePath := noExtension(onlyBase(filePath(f))) + "_pb.rb" // e.g. "service_pb.rb"
+
+		rubyCode := g.generateRubyCode(f, pbFileRelativePath)
+		respFile := &plugin.CodeGeneratorResponse_File{
+			Name:    proto.String(twirpFileName),
+			Content: proto.String(rubyCode),
+		}
+		resp.File = append(resp.File, respFile)
+
+		for _, p := range g.activePlugins {
+			resp.File = append(resp.File, p.ExtraFiles(g, f)...)
+		}
+	}
+
+	return resp
+}
+
+// TwirpFileName computes the output path for the generated _twirp.rb file,
+// honoring the "paths" generator parameter. Plugins emitting sidecar files
+// alongside it (e.g. a ".rbi") should derive their own name from this one so
+// that both land in the same directory.
+func (g *Generator) TwirpFileName(f *descriptor.FileDescriptorProto) string {
+	base := noExtension(onlyBase(filePath(f))) + "_twirp.rb" // e.g. "service_twirp.rb"
+
+	if g.params.paths == "import" {
+		var dirParts []string
+		for _, m := range g.RubyModules(f) {
+			dirParts = append(dirParts, snakeCase(m))
+		}
+		return strings.Join(append(dirParts, base), "/")
+	}
+
+	return noExtension(filePath(f)) + "_twirp.rb" // e.g. "hello_world/service_twirp.rb"
+}
+
+// RubyModules returns the Ruby module nesting a file's generated code will
+// live under, derived from the file's ruby_package option or its proto
+// package.
+func (g *Generator) RubyModules(file *descriptor.FileDescriptorProto) []string {
+	if file.Options != nil && file.Options.RubyPackage != nil {
+		return strings.Split(*file.Options.RubyPackage, "::")
+	}
+	return splitRubyConstants(file.GetPackage())
+}
+
+func (g *Generator) generateRubyCode(file *descriptor.FileDescriptorProto, pbFileRelativePath string) string {
+	b := new(bytes.Buffer)
+	print(b, "# Code generated by protoc-gen-twirp_ruby %s, DO NOT EDIT.", g.version)
+	print(b, "require 'twirp'")
+	if g.params.requirePrefix != "" {
+		print(b, "require '%s/%s'", g.params.requirePrefix, noExtension(pbFileRelativePath))
+	} else {
+		print(b, "require_relative '%s'", pbFileRelativePath) // require generated file with messages
+	}
+	print(b, "")
+
+	indent := Indentation(0)
+	pkgName := file.GetPackage()
+	modules := g.RubyModules(file)
+	srcInfo := sourceInfoMap(file)
+
+	for _, m := range modules {
+		print(b, "%smodule %s", indent, m)
+		indent += 1
+	}
+
+	for i, service := range file.Service {
+		svcName := service.GetName()
+		svcComment := commentLines(srcInfo[pathKey(6, int32(i))])
+
+		if g.params.emitComments {
+			g.writeComment(b, indent, svcComment)
+		}
+		print(b, "%sclass %sService < Twirp::Service", indent, camelCase(svcName))
+		if pkgName != "" {
+			print(b, "%s  package '%s'", indent, pkgName)
+		}
+		print(b, "%s  service '%s'", indent, svcName)
+		for j, method := range service.GetMethod() {
+			rpcName := method.GetName()
+			rpcInput := g.ToRubyType(method.GetInputType())
+			rpcOutput := g.ToRubyType(method.GetOutputType())
+
+			if g.params.emitComments {
+				methodComment := commentLines(srcInfo[pathKey(6, int32(i), 2, int32(j))])
+				g.writeComment(b, indent+1, methodComment)
+				if len(methodComment) > 0 {
+					print(b, "%s  #", indent)
+				}
+				print(b, "%s  # @param req [%s]", indent, rpcInput)
+				print(b, "%s  # @return [%s]", indent, rpcOutput)
+			}
+			print(b, "%s  rpc :%s, %s, %s, :ruby_method => :%s",
+				indent, rpcName, rpcInput, rpcOutput, snakeCase(rpcName))
+		}
+		print(b, "%send", indent)
+		print(b, "")
+
+		if g.params.emitComments {
+			g.writeComment(b, indent, svcComment)
+		}
+		print(b, "%sclass %sClient < Twirp::Client", indent, camelCase(svcName))
+		print(b, "%s  client_for %sService", indent, camelCase(svcName))
+		print(b, "%send", indent)
+
+		for _, p := range g.activePlugins {
+			p.GenerateService(g, service, indent, b)
+		}
+
+		if i < len(file.Service)-1 {
+			print(b, "")
+		}
+	}
+
+	for range modules {
+		indent -= 1
+		print(b, "%send", indent)
+	}
+
+	for _, p := range g.activePlugins {
+		p.GenerateFile(g, file, b)
+	}
+
+	return b.String()
+}
+
+// sourceInfoMap indexes a file's SourceCodeInfo locations by their path, so
+// that comments can be looked up for a given descriptor (e.g. a service or
+// method) by its field-number path, as described in descriptor.proto.
+func sourceInfoMap(file *descriptor.FileDescriptorProto) map[string]*descriptor.SourceCodeInfo_Location {
+	m := make(map[string]*descriptor.SourceCodeInfo_Location)
+	for _, loc := range file.GetSourceCodeInfo().GetLocation() {
+		m[pathKey(loc.Path...)] = loc
+	}
+	return m
+}
+
+// pathKey builds the map key used by sourceInfoMap from a SourceCodeInfo path.
+func pathKey(path ...int32) string {
+	parts := make([]string, len(path))
+	for i, p := range path {
+		parts[i] = strconv.Itoa(int(p))
+	}
+	return strings.Join(parts, ",")
+}
+
+// commentLines extracts the leading (or, failing that, trailing) comment of
+// a SourceCodeInfo location as a slice of lines, ready to be printed one per
+// YARD comment line.
+func commentLines(loc *descriptor.SourceCodeInfo_Location) []string {
+	if loc == nil {
+		return nil
+	}
+
+	text := loc.GetLeadingComments()
+	if text == "" {
+		text = loc.GetTrailingComments()
+	}
+	text = strings.TrimSuffix(text, "\n")
+	if text == "" {
+		return nil
+	}
+
+	return strings.Split(text, "\n")
+}
+
+// writeComment prints lines as `# `-prefixed Ruby comments at the given
+// indentation, preserving blank comment lines.
+func (g *Generator) writeComment(b *bytes.Buffer, indent Indentation, lines []string) {
+	for _, line := range lines {
+		line = strings.TrimPrefix(line, " ")
+		if line == "" {
+			print(b, "%s#", indent)
+		} else {
+			print(b, "%s# %s", indent, line)
+		}
+	}
+}
+
+// findProtoFilesToGenerate selects descriptor proto files that were explicitly listed on the command-line.
+func (g *Generator) findProtoFilesToGenerate() {
+	for _, name := range g.genReq.FileToGenerate { // explicitly listed on the command-line
+		for _, f := range g.genReq.ProtoFile { // all files and everything they import
+			if f.GetName() == name { // match
+				g.genFiles = append(g.genFiles, f)
+				continue
+			}
+		}
+	}
+
+	for _, f := range g.genReq.ProtoFile {
+		if fileDescSliceContains(g.genFiles, f) {
+			g.fileToGoPackageName[f] = ""
+		} else {
+			g.fileToGoPackageName[f] = f.GetPackage()
+		}
+	}
+}
+
+// Indentation represents the level of Ruby indentation for a block of code.
+// It implements the fmt.Stringer interface to output the correct number of
+// spaces for the given level of indentation.
+type Indentation int
+
+func (i Indentation) String() string {
+	return strings.Repeat("  ", int(i))
+}
+
+func print(buf *bytes.Buffer, tpl string, args ...interface{}) {
+	buf.WriteString(fmt.Sprintf(tpl, args...))
+	buf.WriteByte('\n')
+}
+
+func filePath(f *descriptor.FileDescriptorProto) string {
+	return *f.Name
+}
+
+func onlyBase(path string) string {
+	return filepath.Base(path)
+}
+
+func noExtension(path string) string {
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext)
+}
+
+// ToRubyType converts a fully-qualified protobuf message type reference to a
+// Ruby constant. The package prefix is left off for messages defined in a
+// file being generated, and kept for messages from imported files.
+// e.g. ToRubyType(".foo.my_message") => "MyMessage" (foo is being generated)
+// e.g. ToRubyType(".google.protobuf.Empty") => "Google::Protobuf::Empty" (imported)
+//
+// ToRubyType only ever resolves message types, so the synthetic oneofs
+// protoc generates for proto3 `optional` scalar fields (which live on the
+// containing message's OneofDescriptorProto, not as message types of their
+// own) never reach def.Lineage() and can't be mistaken for a nested Ruby
+// module here. No special-casing is needed in ToRubyType or
+// splitRubyConstants for them.
+func (g *Generator) ToRubyType(protoType string) string {
+	def := g.reg.MessageDefinition(protoType)
+	if def == nil {
+		panic(genError("could not find message for " + protoType))
+	}
+
+	var prefix string
+	if pkg := g.fileToGoPackageName[def.File]; pkg != "" {
+		prefix = strings.Join(splitRubyConstants(pkg), "::") + "::"
+	}
+
+	var name string
+	for _, parent := range def.Lineage() {
+		name += camelCase(parent.Descriptor.GetName()) + "::"
+	}
+	name += camelCase(def.Descriptor.GetName())
+	return prefix + name
+}
+
+// splitRubyConstants converts a namespaced protobuf type (package name or mesasge)
+// to a list of names that can be used as Ruby constants.
+// e.g. splitRubyConstants("my.cool.package") => ["My", "Cool", "Package"]
+// e.g. splitRubyConstants("google.protobuf.Empty") => ["Google", "Protobuf", "Empty"]
+func splitRubyConstants(protoPckgName string) []string {
+	if protoPckgName == "" {
+		return []string{} // no modules
+	}
+
+	parts := []string{}
+	for _, p := range strings.Split(protoPckgName, ".") {
+		parts = append(parts, camelCase(p))
+	}
+	return parts
+}
+
+// snakeCase converts a string from CamelCase to snake_case.
+func snakeCase(s string) string {
+	var buf bytes.Buffer
+	for i, r := range s {
+		if unicode.IsUpper(r) && i > 0 {
+			fmt.Fprintf(&buf, "_")
+		}
+		r = unicode.ToLower(r)
+		fmt.Fprintf(&buf, "%c", r)
+	}
+	return buf.String()
+}
+
+// camelCase converts a string from snake_case to CamelCased.
+// If there is an interior underscore followed by a lower case letter, drop the
+// underscore and convert the letter to upper case. There is a remote
+// possibility of this rewrite causing a name collision, but it's so remote
+// we're prepared to pretend it's nonexistent - since the C++ generator
+// lowercases names, it's extremely unlikely to have two fields with different
+// capitalizations. In short, _my_field_name_2 becomes XMyFieldName2.
+func camelCase(s string) string {
+	if s == "" {
+		return ""
+	}
+	t := make([]byte, 0, 32)
+	i := 0
+	if s[0] == '_' {
+		// Need a capital letter; drop the '_'.
+		t = append(t, 'X')
+		i++
+	}
+	// Invariant: if the next letter is lower case, it must be converted
+	// to upper case.
+	//
+	// That is, we process a word at a time, where words are marked by _ or upper
+	// case letter. Digits are treated as words.
+	for ; i < len(s); i++ {
+		c := s[i]
+		if c == '_' && i+1 < len(s) && (isASCIILower(s[i+1]) || isASCIIDigit(s[i+1])) {
+			continue // Skip the underscore in s.
+		}
+		// Assume we have a letter now - if not, it's a bogus identifier. The next
+		// word is a sequence of characters that must start upper case.
+		if isASCIILower(c) {
+			c ^= ' ' // Make it a capital letter.
+		}
+		t = append(t, c) // Guaranteed not lower case.
+		// Accept lower case sequence that follows.
+		for i+1 < len(s) && (isASCIILower(s[i+1]) || isASCIIDigit(s[i+1])) {
+			i++
+			t = append(t, s[i])
+		}
+	}
+	return string(t)
+}
+
+// Is c an ASCII lower-case letter?
+func isASCIILower(c byte) bool {
+	return 'a' <= c && c <= 'z'
+}
+
+// Is c an ASCII digit?
+func isASCIIDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
@@ -0,0 +1,73 @@
+package generator_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	plugin "github.com/golang/protobuf/protoc-gen-go/plugin"
+
+	"github.com/twitchtv/twirp-ruby/generator"
+	_ "github.com/twitchtv/twirp-ruby/generator/plugins/acme" // registers "acme" via init()
+)
+
+// TestMultiplePluginsCommaSeparated proves that `plugins=sorbet,acme` — the
+// comma-separated list syntax the backlog request spelled out — activates
+// both plugins in one run, not just a single `plugins=name`. It also
+// exercises two Plugins from two different packages (the built-in sorbet
+// plugin and the externally-registered acme plugin) running side by side.
+func TestMultiplePluginsCommaSeparated(t *testing.T) {
+	file := &descriptor.FileDescriptorProto{
+		Name:    proto.String("hello_world/service.proto"),
+		Package: proto.String("hello_world"),
+		Service: []*descriptor.ServiceDescriptorProto{
+			{
+				Name: proto.String("Greeter"),
+				Method: []*descriptor.MethodDescriptorProto{
+					{
+						Name:       proto.String("Hello"),
+						InputType:  proto.String(".hello_world.HelloRequest"),
+						OutputType: proto.String(".hello_world.HelloReply"),
+					},
+				},
+			},
+		},
+		MessageType: []*descriptor.DescriptorProto{
+			{Name: proto.String("HelloRequest")},
+			{Name: proto.String("HelloReply")},
+		},
+	}
+
+	req := &plugin.CodeGeneratorRequest{
+		FileToGenerate: []string{file.GetName()},
+		Parameter:      proto.String("plugins=sorbet,acme"),
+		ProtoFile:      []*descriptor.FileDescriptorProto{file},
+	}
+
+	resp := generator.New(req, "test").Generate()
+	if resp.GetError() != "" {
+		t.Fatalf("Generate() returned error: %s", resp.GetError())
+	}
+
+	var rbiFile, acmeFile *plugin.CodeGeneratorResponse_File
+	for _, f := range resp.File {
+		switch {
+		case strings.HasSuffix(f.GetName(), ".rbi"):
+			rbiFile = f
+		case strings.HasSuffix(f.GetName(), ".acme"):
+			acmeFile = f
+		}
+	}
+
+	if rbiFile == nil {
+		t.Fatal("expected sorbet's .rbi sidecar file when plugins=sorbet,acme is set")
+	}
+	if !strings.Contains(rbiFile.GetContent(), "sig { params(req: HelloRequest).returns(HelloReply) }") {
+		t.Errorf("unexpected .rbi content: %s", rbiFile.GetContent())
+	}
+
+	if acmeFile == nil {
+		t.Fatal("expected acme's .acme sidecar file when plugins=sorbet,acme is set")
+	}
+}
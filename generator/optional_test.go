@@ -0,0 +1,90 @@
+// Copyright 2018 Twitch Interactive, Inc.  All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the License is
+// located at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// or in the "license" file accompanying this file. This file is distributed on
+// an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package generator
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	plugin "github.com/golang/protobuf/protoc-gen-go/plugin"
+)
+
+// TestProto3OptionalField exercises a message with a proto3 `optional`
+// scalar field, which protoc represents as a field with a synthetic,
+// single-field oneof. The generator should still resolve HelloRequest
+// correctly and advertise support for the feature.
+func TestProto3OptionalField(t *testing.T) {
+	file := &descriptor.FileDescriptorProto{
+		Name:    proto.String("hello_world/service.proto"),
+		Package: proto.String("hello_world"),
+		Syntax:  proto.String("proto3"),
+		MessageType: []*descriptor.DescriptorProto{
+			{
+				Name: proto.String("HelloRequest"),
+				Field: []*descriptor.FieldDescriptorProto{
+					{
+						Name:           proto.String("foo"),
+						Number:         proto.Int32(1),
+						Label:          descriptor.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+						Type:           descriptor.FieldDescriptorProto_TYPE_STRING.Enum(),
+						OneofIndex:     proto.Int32(0),
+						Proto3Optional: proto.Bool(true),
+					},
+				},
+				OneofDecl: []*descriptor.OneofDescriptorProto{
+					{Name: proto.String("_foo")},
+				},
+			},
+			{Name: proto.String("HelloReply")},
+		},
+		Service: []*descriptor.ServiceDescriptorProto{
+			{
+				Name: proto.String("Greeter"),
+				Method: []*descriptor.MethodDescriptorProto{
+					{
+						Name:       proto.String("Hello"),
+						InputType:  proto.String(".hello_world.HelloRequest"),
+						OutputType: proto.String(".hello_world.HelloReply"),
+					},
+				},
+			},
+		},
+	}
+
+	req := &plugin.CodeGeneratorRequest{
+		FileToGenerate: []string{file.GetName()},
+		ProtoFile:      []*descriptor.FileDescriptorProto{file},
+	}
+
+	resp := New(req, "test").Generate()
+	if resp.GetError() != "" {
+		t.Fatalf("Generate() returned error: %s", resp.GetError())
+	}
+
+	wantFeature := uint64(plugin.CodeGeneratorResponse_FEATURE_PROTO3_OPTIONAL)
+	if resp.GetSupportedFeatures() != wantFeature {
+		t.Errorf("SupportedFeatures = %d, want %d", resp.GetSupportedFeatures(), wantFeature)
+	}
+
+	if len(resp.File) != 1 {
+		t.Fatalf("expected 1 generated file, got %d", len(resp.File))
+	}
+
+	content := resp.File[0].GetContent()
+	if !strings.Contains(content, "rpc :Hello, HelloRequest, HelloReply, :ruby_method => :hello") {
+		t.Errorf("expected HelloRequest/HelloReply to resolve correctly, got:\n%s", content)
+	}
+}
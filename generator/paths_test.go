@@ -0,0 +1,106 @@
+package generator
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	plugin "github.com/golang/protobuf/protoc-gen-go/plugin"
+)
+
+func pathsTestFile() *descriptor.FileDescriptorProto {
+	return &descriptor.FileDescriptorProto{
+		Name:    proto.String("hello_world/service.proto"),
+		Package: proto.String("hello.world"),
+		MessageType: []*descriptor.DescriptorProto{
+			{Name: proto.String("HelloRequest")},
+			{Name: proto.String("HelloReply")},
+		},
+		Service: []*descriptor.ServiceDescriptorProto{
+			{
+				Name: proto.String("Greeter"),
+				Method: []*descriptor.MethodDescriptorProto{
+					{
+						Name:       proto.String("Hello"),
+						InputType:  proto.String(".hello.world.HelloRequest"),
+						OutputType: proto.String(".hello.world.HelloReply"),
+					},
+				},
+			},
+		},
+	}
+}
+
+func generateWithParameter(t *testing.T, parameter string) *plugin.CodeGeneratorResponse {
+	t.Helper()
+	file := pathsTestFile()
+	req := &plugin.CodeGeneratorRequest{
+		FileToGenerate: []string{file.GetName()},
+		ProtoFile:      []*descriptor.FileDescriptorProto{file},
+	}
+	if parameter != "" {
+		req.Parameter = proto.String(parameter)
+	}
+	return New(req, "test").Generate()
+}
+
+func TestPathsSourceRelativeIsTheDefault(t *testing.T) {
+	resp := generateWithParameter(t, "")
+	if resp.GetError() != "" {
+		t.Fatalf("Generate() returned error: %s", resp.GetError())
+	}
+	if got, want := resp.File[0].GetName(), "hello_world/service_twirp.rb"; got != want {
+		t.Errorf("file name = %q, want %q", got, want)
+	}
+}
+
+func TestPathsImportNestsUnderRubyPackage(t *testing.T) {
+	resp := generateWithParameter(t, "paths=import")
+	if resp.GetError() != "" {
+		t.Fatalf("Generate() returned error: %s", resp.GetError())
+	}
+	// "hello.world" => modules ["Hello", "World"] => directories "hello/world".
+	if got, want := resp.File[0].GetName(), "hello/world/service_twirp.rb"; got != want {
+		t.Errorf("file name = %q, want %q", got, want)
+	}
+}
+
+func TestUnsupportedPathsValueIsAnError(t *testing.T) {
+	resp := generateWithParameter(t, "paths=nonsense")
+	if resp.GetError() == "" {
+		t.Fatal("expected an error for an unsupported paths value")
+	}
+}
+
+func TestRequirePrefixRewritesTheRequireLine(t *testing.T) {
+	resp := generateWithParameter(t, "require_prefix=myapp/pb")
+	if resp.GetError() != "" {
+		t.Fatalf("Generate() returned error: %s", resp.GetError())
+	}
+	content := resp.File[0].GetContent()
+	if !strings.Contains(content, "require 'myapp/pb/service_pb'") {
+		t.Errorf("expected require_prefix to rewrite the require line, got:\n%s", content)
+	}
+	if strings.Contains(content, "require_relative") {
+		t.Errorf("did not expect a require_relative line when require_prefix is set, got:\n%s", content)
+	}
+}
+
+func TestWithoutRequirePrefixUsesRequireRelative(t *testing.T) {
+	resp := generateWithParameter(t, "")
+	content := resp.File[0].GetContent()
+	if !strings.Contains(content, "require_relative 'service_pb.rb'") {
+		t.Errorf("expected a require_relative line, got:\n%s", content)
+	}
+}
+
+func TestUnknownGeneratorParameterIsAnError(t *testing.T) {
+	resp := generateWithParameter(t, "bogus=1")
+	if resp.GetError() == "" {
+		t.Fatal("expected an error for an unknown generator parameter")
+	}
+	if !strings.Contains(resp.GetError(), "bogus") {
+		t.Errorf("expected error to mention the bad key, got: %s", resp.GetError())
+	}
+}
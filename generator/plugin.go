@@ -0,0 +1,56 @@
+// Copyright 2018 Twitch Interactive, Inc.  All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the License is
+// located at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// or in the "license" file accompanying this file. This file is distributed on
+// an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package generator
+
+import (
+	"bytes"
+
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	plugin "github.com/golang/protobuf/protoc-gen-go/plugin"
+)
+
+// Plugin lets downstream users extend the Ruby code this generator emits —
+// e.g. Sorbet sig blocks, custom base classes, RBS signature files, or
+// interceptor hooks — without forking this package. A Plugin lives in its
+// own Go package, imports "github.com/twitchtv/twirp-ruby/generator", and
+// registers itself with RegisterPlugin (typically from an init function).
+// It's activated by name via the `plugins=name1,name2` generator parameter.
+type Plugin interface {
+	// Name identifies the plugin for the `plugins=` generator parameter.
+	Name() string
+
+	// GenerateService is called once per service in a generated file, right
+	// after the core Twirp::Service/Twirp::Client classes have been written
+	// to buf, and may append further Ruby code at the given indentation.
+	GenerateService(g *Generator, svc *descriptor.ServiceDescriptorProto, indent Indentation, buf *bytes.Buffer)
+
+	// GenerateFile is called once per generated file, after all of its
+	// services have been processed, and may append further Ruby code to the
+	// same file via buf.
+	GenerateFile(g *Generator, file *descriptor.FileDescriptorProto, buf *bytes.Buffer)
+
+	// ExtraFiles returns additional CodeGeneratorResponse files the plugin
+	// wants to emit alongside the main _twirp.rb file, e.g. a sidecar
+	// "service_twirp.rbi", or nil if it has none.
+	ExtraFiles(g *Generator, file *descriptor.FileDescriptorProto) []*plugin.CodeGeneratorResponse_File
+}
+
+var registeredPlugins = make(map[string]Plugin)
+
+// RegisterPlugin makes a Plugin available for activation via the `plugins=`
+// generator parameter. It is typically called from an init function,
+// mirroring protoc-gen-gogo's plugin registration model.
+func RegisterPlugin(p Plugin) {
+	registeredPlugins[p.Name()] = p
+}
@@ -0,0 +1,43 @@
+// Package acme is a standalone example of a third-party generator.Plugin:
+// it lives in its own Go package, imports only the generator package's
+// exported API, and registers itself from an init function — the same way
+// a real downstream plugin would, without touching the generator package's
+// source.
+package acme
+
+import (
+	"bytes"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	plugin "github.com/golang/protobuf/protoc-gen-go/plugin"
+
+	"github.com/twitchtv/twirp-ruby/generator"
+)
+
+func init() {
+	generator.RegisterPlugin(&Plugin{})
+}
+
+// Plugin appends an Acme-branded comment after every generated service and
+// emits a small sidecar file per generated .proto, purely to demonstrate the
+// Plugin hooks end-to-end. Its GenerateFile hook adds nothing.
+type Plugin struct{}
+
+func (p *Plugin) Name() string { return "acme" }
+
+func (p *Plugin) GenerateService(g *generator.Generator, svc *descriptor.ServiceDescriptorProto, indent generator.Indentation, buf *bytes.Buffer) {
+	buf.WriteString(indent.String() + "# acme: hello from " + svc.GetName() + "Service\n")
+}
+
+func (p *Plugin) GenerateFile(g *generator.Generator, file *descriptor.FileDescriptorProto, buf *bytes.Buffer) {
+}
+
+func (p *Plugin) ExtraFiles(g *generator.Generator, file *descriptor.FileDescriptorProto) []*plugin.CodeGeneratorResponse_File {
+	return []*plugin.CodeGeneratorResponse_File{
+		{
+			Name:    proto.String(g.TwirpFileName(file) + ".acme"),
+			Content: proto.String("acme sidecar for " + file.GetPackage() + "\n"),
+		},
+	}
+}
@@ -0,0 +1,75 @@
+package acme_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	plugin "github.com/golang/protobuf/protoc-gen-go/plugin"
+
+	"github.com/twitchtv/twirp-ruby/generator"
+	_ "github.com/twitchtv/twirp-ruby/generator/plugins/acme" // registers "acme" via init()
+)
+
+// TestAcmePluginEndToEnd proves a Plugin implemented in a wholly separate Go
+// package — never touching the generator package's source — can register
+// itself and be activated with the plugins= parameter through nothing but
+// generator's exported API (generator.New(...).Generate()).
+func TestAcmePluginEndToEnd(t *testing.T) {
+	file := &descriptor.FileDescriptorProto{
+		Name:    proto.String("hello_world/service.proto"),
+		Package: proto.String("hello_world"),
+		Service: []*descriptor.ServiceDescriptorProto{
+			{
+				Name: proto.String("Greeter"),
+				Method: []*descriptor.MethodDescriptorProto{
+					{
+						Name:       proto.String("Hello"),
+						InputType:  proto.String(".hello_world.HelloRequest"),
+						OutputType: proto.String(".hello_world.HelloReply"),
+					},
+				},
+			},
+		},
+		MessageType: []*descriptor.DescriptorProto{
+			{Name: proto.String("HelloRequest")},
+			{Name: proto.String("HelloReply")},
+		},
+	}
+
+	req := &plugin.CodeGeneratorRequest{
+		FileToGenerate: []string{file.GetName()},
+		Parameter:      proto.String("plugins=acme"),
+		ProtoFile:      []*descriptor.FileDescriptorProto{file},
+	}
+
+	resp := generator.New(req, "test").Generate()
+	if resp.GetError() != "" {
+		t.Fatalf("Generate() returned error: %s", resp.GetError())
+	}
+
+	var rubyFile, acmeFile *plugin.CodeGeneratorResponse_File
+	for _, f := range resp.File {
+		switch {
+		case strings.HasSuffix(f.GetName(), "_twirp.rb"):
+			rubyFile = f
+		case strings.HasSuffix(f.GetName(), ".acme"):
+			acmeFile = f
+		}
+	}
+
+	if rubyFile == nil {
+		t.Fatal("expected a _twirp.rb file in the response")
+	}
+	if !strings.Contains(rubyFile.GetContent(), "# acme: hello from GreeterService") {
+		t.Errorf("expected plugin comment in generated Ruby, got:\n%s", rubyFile.GetContent())
+	}
+
+	if acmeFile == nil {
+		t.Fatal("expected the plugin's sidecar .acme file in the response")
+	}
+	if !strings.Contains(acmeFile.GetContent(), "acme sidecar for hello_world") {
+		t.Errorf("unexpected sidecar content: %s", acmeFile.GetContent())
+	}
+}
@@ -0,0 +1,91 @@
+// Copyright 2018 Twitch Interactive, Inc.  All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the License is
+// located at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// or in the "license" file accompanying this file. This file is distributed on
+// an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package generator
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	plugin "github.com/golang/protobuf/protoc-gen-go/plugin"
+)
+
+func init() {
+	RegisterPlugin(&sorbetPlugin{})
+}
+
+// sorbetPlugin emits a sidecar ".rbi" file with T::Sig-style method
+// signatures for every RPC, declared on each generated Service class. The
+// Client classes are not included in the .rbi.
+type sorbetPlugin struct{}
+
+func (s *sorbetPlugin) Name() string { return "sorbet" }
+
+// GenerateService is a no-op: sig blocks are emitted into the sidecar .rbi
+// file by ExtraFiles rather than into the main _twirp.rb file.
+func (s *sorbetPlugin) GenerateService(g *Generator, svc *descriptor.ServiceDescriptorProto, indent Indentation, buf *bytes.Buffer) {
+}
+
+// GenerateFile is a no-op: see GenerateService.
+func (s *sorbetPlugin) GenerateFile(g *Generator, file *descriptor.FileDescriptorProto, buf *bytes.Buffer) {
+}
+
+func (s *sorbetPlugin) ExtraFiles(g *Generator, file *descriptor.FileDescriptorProto) []*plugin.CodeGeneratorResponse_File {
+	if len(file.Service) == 0 {
+		return nil
+	}
+
+	b := new(bytes.Buffer)
+	print(b, "# typed: true")
+	print(b, "")
+
+	indent := Indentation(0)
+	modules := g.RubyModules(file)
+	for _, m := range modules {
+		print(b, "%smodule %s", indent, m)
+		indent += 1
+	}
+
+	for i, service := range file.Service {
+		svcName := camelCase(service.GetName())
+
+		print(b, "%sclass %sService", indent, svcName)
+		print(b, "%s  extend T::Sig", indent)
+		print(b, "")
+		for _, method := range service.GetMethod() {
+			rpcInput := g.ToRubyType(method.GetInputType())
+			rpcOutput := g.ToRubyType(method.GetOutputType())
+			print(b, "%s  sig { params(req: %s).returns(%s) }", indent, rpcInput, rpcOutput)
+			print(b, "%s  def %s(req); end", indent, snakeCase(method.GetName()))
+		}
+		print(b, "%send", indent)
+		if i < len(file.Service)-1 {
+			print(b, "")
+		}
+	}
+
+	for range modules {
+		indent -= 1
+		print(b, "%send", indent)
+	}
+
+	rbiName := strings.TrimSuffix(g.TwirpFileName(file), ".rb") + ".rbi"
+	return []*plugin.CodeGeneratorResponse_File{
+		{
+			Name:    proto.String(rbiName),
+			Content: proto.String(b.String()),
+		},
+	}
+}
@@ -0,0 +1,78 @@
+package generator
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	plugin "github.com/golang/protobuf/protoc-gen-go/plugin"
+)
+
+func testFile() *descriptor.FileDescriptorProto {
+	return &descriptor.FileDescriptorProto{
+		Name:    proto.String("hello_world/service.proto"),
+		Package: proto.String("hello_world"),
+		MessageType: []*descriptor.DescriptorProto{
+			{Name: proto.String("HelloRequest")},
+			{Name: proto.String("HelloReply")},
+		},
+		Service: []*descriptor.ServiceDescriptorProto{
+			{
+				Name: proto.String("Greeter"),
+				Method: []*descriptor.MethodDescriptorProto{
+					{
+						Name:       proto.String("Hello"),
+						InputType:  proto.String(".hello_world.HelloRequest"),
+						OutputType: proto.String(".hello_world.HelloReply"),
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestSorbetPluginEmitsRBI(t *testing.T) {
+	file := testFile()
+	req := &plugin.CodeGeneratorRequest{
+		FileToGenerate: []string{file.GetName()},
+		Parameter:      proto.String("plugins=sorbet"),
+		ProtoFile:      []*descriptor.FileDescriptorProto{file},
+	}
+
+	resp := New(req, "test").Generate()
+	if resp.GetError() != "" {
+		t.Fatalf("Generate() returned error: %s", resp.GetError())
+	}
+
+	var rbiFile *plugin.CodeGeneratorResponse_File
+	for _, f := range resp.File {
+		if strings.HasSuffix(f.GetName(), ".rbi") {
+			rbiFile = f
+		}
+	}
+
+	if rbiFile == nil {
+		t.Fatal("expected a sidecar .rbi file when plugins=sorbet is set")
+	}
+	if rbiFile.GetName() != "hello_world/service_twirp.rbi" {
+		t.Errorf("unexpected .rbi file name: %s", rbiFile.GetName())
+	}
+	if !strings.Contains(rbiFile.GetContent(), "sig { params(req: HelloRequest).returns(HelloReply) }") {
+		t.Errorf("unexpected .rbi content:\n%s", rbiFile.GetContent())
+	}
+}
+
+func TestUnknownPluginReturnsError(t *testing.T) {
+	file := testFile()
+	req := &plugin.CodeGeneratorRequest{
+		FileToGenerate: []string{file.GetName()},
+		Parameter:      proto.String("plugins=does_not_exist"),
+		ProtoFile:      []*descriptor.FileDescriptorProto{file},
+	}
+
+	resp := New(req, "test").Generate()
+	if resp.GetError() == "" {
+		t.Fatal("expected Generate() to report an error for an unregistered plugin")
+	}
+}